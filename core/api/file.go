@@ -0,0 +1,25 @@
+// Package api defines the types a Resolver produces and a Retriever consumes,
+// kept separate from core to avoid a dependency cycle with provider packages.
+package api
+
+import "net/url"
+
+// FileSizeUnknown marks a File whose Size() could not be determined, e.g. a
+// HEAD response with no Content-Length.
+const FileSizeUnknown = -1
+
+// Provider identifies the source a File came from.
+type Provider interface {
+	Name() string
+}
+
+// File is the metadata a Resolver produces for a remote file.
+type File interface {
+	Provider() Provider
+	Name() string
+	URL() *url.URL
+	Size() int64
+	// Checksum returns the checksum bytes and the algorithm name (e.g.
+	// "SHA1"), or (nil, "", nil) if the provider doesn't supply one.
+	Checksum() ([]byte, string, error)
+}
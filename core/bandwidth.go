@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uget/uget/utils/units"
+)
+
+// BandwidthSnapshot is a point-in-time view of a Client's network usage.
+// Rates are bytes per second, averaged over the stated window. The Human
+// fields format their corresponding value with units.BytesSize, for callers
+// that just want to display it (e.g. the /bandwidth endpoint).
+type BandwidthSnapshot struct {
+	BytesIn             int64   `json:"bytes_in"`
+	BytesInHuman        string  `json:"bytes_in_human"`
+	BytesOut            int64   `json:"bytes_out"`
+	BytesOutHuman       string  `json:"bytes_out_human"`
+	BytesInPerSec       float64 `json:"bytes_in_per_sec"`
+	BytesInPerSecHuman  string  `json:"bytes_in_per_sec_human"`
+	BytesOutPerSec      float64 `json:"bytes_out_per_sec"`
+	BytesOutPerSecHuman string  `json:"bytes_out_per_sec_human"`
+	BytesInPerMin       float64 `json:"bytes_in_per_min"`
+	BytesInPerMinHuman  string  `json:"bytes_in_per_min_human"`
+	BytesOutPerMin      float64 `json:"bytes_out_per_min"`
+	BytesOutPerMinHuman string  `json:"bytes_out_per_min_human"`
+	ActiveConnections   int32   `json:"active_connections"`
+}
+
+// humanRate formats a bytes-per-second rate with units.BytesSize, appending
+// the implicit "/s" the raw helper doesn't know about.
+func humanRate(bytesPerSec float64) string {
+	return units.BytesSize(bytesPerSec) + "/s"
+}
+
+// bandwidthTick is how often a Client samples its BandwidthMeter for the
+// OnBandwidth hook and the rolling per-second/per-minute rates.
+const bandwidthTick = time.Second
+
+// BandwidthMeter wraps an http.RoundTripper to record bytes read and written
+// across all traffic a Client's httpClient sends: resolve HEAD/GET, provider
+// auth, and retrieval bodies. Providers that read directly off a connection
+// (bypassing httpClient) can still be counted via WrapReader.
+type BandwidthMeter struct {
+	next http.RoundTripper
+
+	totalIn, totalOut int64
+	activeConns       int32
+
+	mu      sync.Mutex
+	samples []bwSample
+}
+
+type bwSample struct {
+	at      time.Time
+	in, out int64
+}
+
+func newBandwidthMeter(next http.RoundTripper) *BandwidthMeter {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &BandwidthMeter{next: next}
+}
+
+// downloadCtxKey is the context.Context key a *Download is stored under, so
+// RoundTrip can attribute a request's bytes to it in addition to the
+// Client-wide totals.
+type downloadCtxKey struct{}
+
+// withDownload returns a context carrying dl. retrieveOne attaches it to the
+// context it hands a Retriever, so a Retriever that threads ctx through to
+// its own *http.Request(s) (via req.WithContext(ctx)) gets its bytes counted
+// against dl, not just the aggregate.
+func withDownload(ctx context.Context, dl *Download) context.Context {
+	return context.WithValue(ctx, downloadCtxKey{}, dl)
+}
+
+// RoundTrip implements http.RoundTripper. A connection counts as active from
+// here until the response body is closed, not just until headers are read,
+// since that's when the bulk of a retrieval's bytes actually flow.
+func (m *BandwidthMeter) RoundTrip(req *http.Request) (*http.Response, error) {
+	dl, _ := req.Context().Value(downloadCtxKey{}).(*Download)
+	if req.Body != nil {
+		req.Body = &countingReadCloser{countingReader{req.Body, &m.totalOut, dl, false}, req.Body, nil}
+	}
+	atomic.AddInt32(&m.activeConns, 1)
+	resp, err := m.next.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		atomic.AddInt32(&m.activeConns, -1)
+		return resp, err
+	}
+	resp.Body = &countingReadCloser{countingReader{resp.Body, &m.totalIn, dl, true}, resp.Body, &m.activeConns}
+	return resp, err
+}
+
+// WrapReader instruments an arbitrary provider-supplied reader (e.g. a
+// streamed retrieval body read outside of httpClient) so its bytes count
+// toward BytesIn.
+func (m *BandwidthMeter) WrapReader(r io.Reader) io.Reader {
+	return &countingReader{r, &m.totalIn, nil, true}
+}
+
+type countingReader struct {
+	r       io.Reader
+	counter *int64
+	// dl, if non-nil, also gets this traffic counted against its BytesIn (if
+	// in) or BytesOut (if !in), alongside the aggregate counter above.
+	dl *Download
+	in bool
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		atomic.AddInt64(c.counter, int64(n))
+		if c.dl != nil {
+			if c.in {
+				atomic.AddInt64(&c.dl.bytesIn, int64(n))
+			} else {
+				atomic.AddInt64(&c.dl.bytesOut, int64(n))
+			}
+		}
+	}
+	return n, err
+}
+
+type countingReadCloser struct {
+	countingReader
+	closer io.Closer
+	// activeConns, if non-nil, is decremented once on Close: the counter
+	// backing BandwidthSnapshot.ActiveConnections for the RoundTrip that
+	// produced this body.
+	activeConns *int32
+}
+
+func (c *countingReadCloser) Close() error {
+	if c.activeConns != nil {
+		atomic.AddInt32(c.activeConns, -1)
+		c.activeConns = nil
+	}
+	return c.closer.Close()
+}
+
+// tick samples the cumulative counters, derives the per-second and
+// per-minute rates from the previous samples, and trims samples older than a
+// minute.
+func (m *BandwidthMeter) tick() BandwidthSnapshot {
+	in := atomic.LoadInt64(&m.totalIn)
+	out := atomic.LoadInt64(&m.totalOut)
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var perSecIn, perSecOut float64
+	if n := len(m.samples); n > 0 {
+		last := m.samples[n-1]
+		if dt := now.Sub(last.at).Seconds(); dt > 0 {
+			perSecIn = float64(in-last.in) / dt
+			perSecOut = float64(out-last.out) / dt
+		}
+	}
+
+	m.samples = append(m.samples, bwSample{now, in, out})
+	cutoff := now.Add(-time.Minute)
+	for len(m.samples) > 1 && m.samples[0].at.Before(cutoff) {
+		m.samples = m.samples[1:]
+	}
+
+	var perMinIn, perMinOut float64
+	if oldest := m.samples[0]; now.After(oldest.at) {
+		dt := now.Sub(oldest.at).Seconds()
+		perMinIn = float64(in-oldest.in) / dt
+		perMinOut = float64(out-oldest.out) / dt
+	}
+
+	return BandwidthSnapshot{
+		BytesIn:             in,
+		BytesInHuman:        units.BytesSize(float64(in)),
+		BytesOut:            out,
+		BytesOutHuman:       units.BytesSize(float64(out)),
+		BytesInPerSec:       perSecIn,
+		BytesInPerSecHuman:  humanRate(perSecIn),
+		BytesOutPerSec:      perSecOut,
+		BytesOutPerSecHuman: humanRate(perSecOut),
+		BytesInPerMin:       perMinIn,
+		BytesInPerMinHuman:  humanRate(perMinIn),
+		BytesOutPerMin:      perMinOut,
+		BytesOutPerMinHuman: humanRate(perMinOut),
+		ActiveConnections:   atomic.LoadInt32(&m.activeConns),
+	}
+}
+
+// Bandwidth returns the current bandwidth snapshot for this Client.
+func (d *Client) Bandwidth() BandwidthSnapshot {
+	return d.bandwidth.tick()
+}
+
+// OnBandwidth calls the given hook with a fresh BandwidthSnapshot roughly
+// every second for as long as the Client is running.
+func (d *Client) OnBandwidth(f func(BandwidthSnapshot)) {
+	d.On(eBandwidth, f)
+}
+
+func (d *Client) watchBandwidth() {
+	ticker := time.NewTicker(bandwidthTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.Emit(eBandwidth, d.bandwidth.tick())
+		case <-d.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestBandwidthMeterActiveConnections guards the fix in eae9c8f: a
+// connection must count as active from RoundTrip until the response body is
+// closed, not just until headers are read.
+func TestBandwidthMeterActiveConnections(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	m := newBandwidthMeter(nil)
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := m.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	if got := m.tick().ActiveConnections; got != 1 {
+		t.Fatalf("ActiveConnections right after RoundTrip = %d, want 1", got)
+	}
+
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if got := m.tick().ActiveConnections; got != 1 {
+		t.Fatalf("ActiveConnections after reading the body but before Close = %d, want 1", got)
+	}
+
+	resp.Body.Close()
+	if got := m.tick().ActiveConnections; got != 0 {
+		t.Fatalf("ActiveConnections after Close = %d, want 0", got)
+	}
+}
+
+// TestBandwidthMeterAttributesToDownload covers the per-Download attribution
+// RoundTrip does via withDownload: a request carrying a *Download in its
+// context gets its bytes counted both against that Download and the
+// Client-wide aggregate, and an unrelated Download sees none of it.
+func TestBandwidthMeterAttributesToDownload(t *testing.T) {
+	const body = "hello world"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	m := newBandwidthMeter(nil)
+	dl := &Download{}
+	unrelated := &Download{}
+
+	ctx := withDownload(context.Background(), dl)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequestWithContext: %v", err)
+	}
+	resp, err := m.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	if got := dl.BytesIn(); got != int64(len(body)) {
+		t.Errorf("dl.BytesIn() = %d, want %d", got, len(body))
+	}
+	if got := unrelated.BytesIn(); got != 0 {
+		t.Errorf("unrelated.BytesIn() = %d, want 0", got)
+	}
+	if got := m.tick().BytesIn; got < int64(len(body)) {
+		t.Errorf("aggregate BytesIn = %d, want >= %d", got, len(body))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/chuckpreslar/emission"
@@ -18,6 +19,8 @@ const (
 	eResolve
 	eDeadend
 	eSkip
+	eRetry
+	eBandwidth
 )
 
 // Client manages downloads
@@ -28,11 +31,16 @@ type Client struct {
 	NoContinue    bool
 	Providers     Providers
 	Accounts      map[string][]Account
+	RetryPolicy   RetryPolicy
 	httpClient    *http.Client
+	transport     *transportSwitch
+	bandwidth     *BandwidthMeter
 	resolverQueue *queue
 	ResolvedQueue *queue
 	retrievers    int // number of retriever/downloader jobs
 	dryrun        bool
+	stop          chan struct{}
+	workers       sync.WaitGroup
 }
 
 // NewClient creates a new Client with 3 retrievers and 1 resolver
@@ -43,14 +51,20 @@ func NewClient() *Client {
 // NewClientWith creates a new Client with the amount of workers provided.
 // If amount is 0, the Client works in resolve-only mode.
 func NewClientWith(retrievers int) *Client {
+	meter := newBandwidthMeter(nil)
+	transport := &transportSwitch{next: meter}
 	return &Client{
 		Emitter:       emission.NewEmitter(),
 		Providers:     RegisteredProviders(),
+		RetryPolicy:   DefaultRetryPolicy,
 		resolverQueue: newQueue(),
 		ResolvedQueue: newQueue(),
 		retrievers:    retrievers,
-		httpClient:    new(http.Client),
+		httpClient:    &http.Client{Transport: transport},
+		transport:     transport,
+		bandwidth:     meter,
 		Accounts:      make(map[string][]Account),
+		stop:          make(chan struct{}),
 	}
 }
 
@@ -73,7 +87,7 @@ func (d *Client) AddURLs(urls []*url.URL) *sync.WaitGroup {
 func (d *Client) configure() {
 	for _, p := range d.Providers {
 		if cfg, ok := p.(Configured); ok {
-			cfg.Configure(&Config{d.Accounts[p.Name()]})
+			cfg.Configure(&Config{Accounts: d.Accounts[p.Name()], HTTPClient: d.httpClient})
 		}
 	}
 }
@@ -82,10 +96,18 @@ func (d *Client) configure() {
 func (d *Client) Start() {
 	logrus.Debugf("Client#Start: %v workers", d.retrievers)
 	d.configure()
-	go d.workResolve()
+	d.workers.Add(1 + d.retrievers)
+	go func() {
+		defer d.workers.Done()
+		d.workResolve()
+	}()
 	for i := 0; i < d.retrievers; i++ {
-		go d.workRetrieve()
+		go func() {
+			defer d.workers.Done()
+			d.workRetrieve()
+		}()
 	}
+	go d.watchBandwidth()
 }
 
 func (d *Client) Use(acc Account) {
@@ -112,16 +134,63 @@ func (d *Client) Resolve() {
 	d.Start()
 }
 
-func (d *Client) Finalize() {
-	d.ResolvedQueue.Finalize()
-	d.resolverQueue.Finalize()
+// Pause stops retriever workers from picking up new jobs once their current
+// one finishes. Already-running jobs are not interrupted. Queued work is
+// untouched; call Resume to continue it.
+func (d *Client) Pause() {
+	<-d.ResolvedQueue.Pause()
+}
+
+// Resume undoes a prior Pause.
+func (d *Client) Resume() {
+	<-d.ResolvedQueue.Resume()
+}
+
+// Paused reports whether the Client is currently paused.
+func (d *Client) Paused() bool {
+	return d.ResolvedQueue.isPaused()
+}
+
+// Finalize marks both queues as finalized and returns a channel that's
+// closed once their dispatch loops have exited *and* every resolve/retrieve
+// worker goroutine has returned, so callers can wait for a graceful shutdown
+// to really finish — including whatever request a worker already had in
+// flight — before tearing the Client down or reconfiguring anything its
+// Providers share with another Client (restart's shared package-global
+// Provider instances, for instance). Anything still queued (not yet popped
+// by a worker) is abandoned, not processed; a caller that needs to resume it
+// later should persist it first, e.g. via SaveState.
+func (d *Client) Finalize() <-chan struct{} {
+	<-d.ResolvedQueue.Finalize()
+	<-d.resolverQueue.Finalize()
+	done := make(chan struct{})
+	go func() {
+		<-d.resolverQueue.Done()
+		<-d.ResolvedQueue.Done()
+		d.workers.Wait()
+		close(done)
+	}()
+	return done
 }
 
+// Stop immediately closes both queues without waiting for them to drain.
+// Prefer Finalize, then StopBackground, for a graceful shutdown.
 func (d *Client) Stop() {
 	close(d.ResolvedQueue.get)
 	close(d.ResolvedQueue.getAll)
+	close(d.ResolvedQueue.getReq)
 	close(d.resolverQueue.get)
 	close(d.resolverQueue.getAll)
+	close(d.resolverQueue.getReq)
+	close(d.stop)
+}
+
+// StopBackground stops the Client's ancillary goroutines (currently just the
+// bandwidth ticker). Call it once Finalize's channel has closed, since the
+// queues will have already shut down their own worker goroutines by then;
+// calling Stop afterwards would double-close their channels.
+func (d *Client) StopBackground() {
+	close(d.stop)
 }
 
 func (d *Client) dryRun(format string, is ...interface{}) bool {
@@ -159,3 +228,12 @@ func (d *Client) OnResolve(f func(*url.URL, File, error)) {
 func (d *Client) OnDeadend(f func(*url.URL)) {
 	d.On(eDeadend, f)
 }
+
+// OnRetry calls the given hook whenever a resolve or retrieve is requeued
+// after a retryable failure, in place of the OnError (retrieve) or OnResolve
+// (resolve) report that would otherwise have carried the error. `attempt`
+// numbers the retry about to be made (1 for the first retry) and `next` is
+// the delay before it.
+func (d *Client) OnRetry(f func(File, int, time.Duration, error)) {
+	d.On(eRetry, f)
+}
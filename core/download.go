@@ -0,0 +1,28 @@
+package core
+
+import "sync/atomic"
+
+// Download describes a retrieval in progress, passed to OnDownload when a
+// retriever is about to start streaming a File's contents.
+type Download struct {
+	// File is the file being retrieved.
+	File File
+	// Offset is the byte offset retrieval starts at (non-zero when
+	// continuing a partial download; see Client.NoContinue).
+	Offset int64
+
+	bytesIn, bytesOut int64
+}
+
+// BytesIn returns how many bytes have been read for this Download so far,
+// the same traffic BandwidthMeter also folds into the Client-wide totals.
+func (d *Download) BytesIn() int64 {
+	return atomic.LoadInt64(&d.bytesIn)
+}
+
+// BytesOut returns how many request body bytes have been written for this
+// Download so far; headers are not counted, so it is typically 0 since
+// retrieval is a GET.
+func (d *Download) BytesOut() int64 {
+	return atomic.LoadInt64(&d.bytesOut)
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule describes one fault to simulate for requests matching URLPattern (and
+// Method, if set). FailureRate is the probability, in [0, 1], that a matching
+// request is faulted; requests that aren't faulted are forwarded without
+// other changes. Latency, if set, delays every matching request, faulted or
+// not.
+type Rule struct {
+	URLPattern string `json:"url_pattern"`
+	Method     string `json:"method,omitempty"`
+
+	FailureRate float64       `json:"failure_rate"`
+	Latency     time.Duration `json:"latency,omitempty"`
+
+	// StatusCode, if non-zero, short-circuits the request with this response
+	// instead of forwarding it. Body is used as the response body.
+	StatusCode int    `json:"status_code,omitempty"`
+	Body       string `json:"body,omitempty"`
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+
+	// DropAfterBytes, if non-zero, lets the request through but severs the
+	// response body after that many bytes, simulating a connection reset
+	// mid-transfer.
+	DropAfterBytes int64 `json:"drop_after_bytes,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// FaultInjector is an http.RoundTripper that wraps another RoundTripper
+// (typically a Client's BandwidthMeter) and deterministically injects
+// failures configured via Rule, so resolvers/retrievers and the retry policy
+// can be exercised without flaky live dependencies.
+type FaultInjector struct {
+	next http.RoundTripper
+	rng  *rand.Rand
+
+	mu    sync.Mutex
+	rules []Rule
+}
+
+// NewFaultInjector creates a FaultInjector wrapping next (http.DefaultTransport
+// if nil), seeded with seed so injected failures are reproducible.
+func NewFaultInjector(next http.RoundTripper, seed int64) *FaultInjector {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &FaultInjector{next: next, rng: rand.New(rand.NewSource(seed))}
+}
+
+// AddRule registers a fault rule, compiling its URLPattern.
+func (fi *FaultInjector) AddRule(r Rule) error {
+	pattern, err := regexp.Compile(r.URLPattern)
+	if err != nil {
+		return fmt.Errorf("faultinjector: invalid url_pattern %q: %v", r.URLPattern, err)
+	}
+	r.pattern = pattern
+	fi.mu.Lock()
+	fi.rules = append(fi.rules, r)
+	fi.mu.Unlock()
+	return nil
+}
+
+// faultProfile is the JSON-serializable form of a FaultInjector's configuration.
+type faultProfile struct {
+	Seed  int64  `json:"seed"`
+	Rules []Rule `json:"rules"`
+}
+
+// LoadFaultProfile reads a JSON-configured set of rules from path, so
+// integration tests (and the server package, under the `debug` build tag)
+// can toggle fault profiles at runtime.
+func LoadFaultProfile(path string) (*FaultInjector, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profile faultProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	fi := NewFaultInjector(nil, profile.Seed)
+	for _, r := range profile.Rules {
+		if err := fi.AddRule(r); err != nil {
+			return nil, err
+		}
+	}
+	return fi, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (fi *FaultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule := fi.match(req)
+	if rule == nil {
+		return fi.next.RoundTrip(req)
+	}
+
+	if rule.Latency > 0 {
+		time.Sleep(rule.Latency)
+	}
+
+	fi.mu.Lock()
+	fault := fi.rng.Float64() < rule.FailureRate
+	fi.mu.Unlock()
+	if !fault {
+		return fi.next.RoundTrip(req)
+	}
+
+	if rule.StatusCode != 0 {
+		return fi.syntheticResponse(req, *rule), nil
+	}
+
+	resp, err := fi.next.RoundTrip(req)
+	if err != nil || rule.DropAfterBytes <= 0 || resp.Body == nil {
+		return resp, err
+	}
+	resp.Body = &droppingBody{body: resp.Body, remaining: rule.DropAfterBytes}
+	return resp, nil
+}
+
+func (fi *FaultInjector) match(req *http.Request) *Rule {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	for i := range fi.rules {
+		r := &fi.rules[i]
+		if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+			continue
+		}
+		if r.pattern != nil && r.pattern.MatchString(req.URL.String()) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (fi *FaultInjector) syntheticResponse(req *http.Request, rule Rule) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	if rule.RetryAfter > 0 {
+		header.Set("Retry-After", strconv.Itoa(int(rule.RetryAfter.Seconds())))
+	}
+	body := rule.Body
+	return &http.Response{
+		Status:     http.StatusText(rule.StatusCode),
+		StatusCode: rule.StatusCode,
+		Proto:      "HTTP/1.1",
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		Request:    req,
+	}
+}
+
+// droppingBody severs the wrapped body after `remaining` bytes have been
+// read, simulating a connection reset mid-transfer.
+type droppingBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (d *droppingBody) Read(p []byte) (int, error) {
+	if d.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.body.Read(p)
+	d.remaining -= int64(n)
+	if err == nil && d.remaining <= 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (d *droppingBody) Close() error {
+	return d.body.Close()
+}
+
+// transportSwitch is installed once as a Client's httpClient.Transport and
+// never replaced, so SetFaultInjector can redirect what it delegates to at
+// runtime without racing the Transport reads that net/http does on every
+// concurrent httpClient.Do call from the resolver/retriever goroutines.
+type transportSwitch struct {
+	mu   sync.RWMutex
+	next http.RoundTripper
+}
+
+func (t *transportSwitch) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	next := t.next
+	t.mu.RUnlock()
+	return next.RoundTrip(req)
+}
+
+func (t *transportSwitch) set(rt http.RoundTripper) {
+	t.mu.Lock()
+	t.next = rt
+	t.mu.Unlock()
+}
+
+// SetFaultInjector installs fi in front of the Client's BandwidthMeter, so
+// traffic still counts toward bandwidth accounting. It replaces any
+// FaultInjector set before rather than stacking on it. Passing nil restores
+// the unfaulted transport. Safe to call while the Client is running.
+func (d *Client) SetFaultInjector(fi *FaultInjector) {
+	if fi == nil {
+		d.transport.set(d.bandwidth)
+		return
+	}
+	fi.next = d.bandwidth
+	d.transport.set(fi)
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/uget/uget/core/api"
+)
+
+// fakeResolver resolves any "fake://" URL by issuing a GET through the
+// Client's instrumented http.Client (so a FaultInjector set on the Client
+// actually sees the request), failing with a retryable error on anything
+// but a 200.
+type fakeResolver struct {
+	target     string
+	httpClient *http.Client
+}
+
+func (f *fakeResolver) Name() string { return "fake" }
+
+func (f *fakeResolver) Configure(cfg *Config) {
+	f.httpClient = cfg.HTTPClient
+}
+
+func (f *fakeResolver) CanResolve(u *url.URL) bool {
+	return u.Scheme == "fake"
+}
+
+func (f *fakeResolver) Resolve(u *url.URL) (api.File, error) {
+	resp, err := f.httpClient.Get(f.target)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &fakeStatusError{resp.StatusCode}
+	}
+	return nil, fmt.Errorf("fake: unexpectedly succeeded")
+}
+
+// fakeStatusError satisfies retry.go's unexported statusCoder interface, the
+// same way b2's statusError does, without needing to import that package.
+type fakeStatusError struct {
+	status int
+}
+
+func (e *fakeStatusError) Error() string   { return fmt.Sprintf("fake: status %d", e.status) }
+func (e *fakeStatusError) StatusCode() int { return e.status }
+
+// TestFaultInjectorDrivesRetryPolicy exercises a FaultInjector-wrapped
+// Client end to end: every request is faulted with a retryable 503, so
+// RetryPolicy should requeue it exactly MaxAttempts-1 times before giving up
+// and reporting the final error.
+func TestFaultInjectorDrivesRetryPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	d := NewClientWith(0)
+	d.RetryPolicy = RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+		Multiplier:      1,
+		Retryable:       defaultRetryable,
+	}
+	d.Providers = Providers{&fakeResolver{target: srv.URL}}
+
+	fi := NewFaultInjector(nil, 1)
+	if err := fi.AddRule(Rule{
+		URLPattern:  ".*",
+		FailureRate: 1,
+		StatusCode:  http.StatusServiceUnavailable,
+	}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	d.SetFaultInjector(fi)
+
+	var retries int
+	var finalErr error
+	done := make(chan struct{})
+	d.OnRetry(func(f File, attempt int, wait time.Duration, err error) {
+		retries++
+	})
+	d.OnResolve(func(u *url.URL, f File, err error) {
+		finalErr = err
+		close(done)
+	})
+
+	u, err := url.Parse("fake://host/path")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	d.AddURLs([]*url.URL{u})
+	d.Start()
+	defer d.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for resolve to give up")
+	}
+
+	if want := d.RetryPolicy.MaxAttempts - 1; retries != want {
+		t.Errorf("retries = %d, want %d", retries, want)
+	}
+	if finalErr == nil {
+		t.Error("expected a final error after exhausting retries, got nil")
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// PersistedRequest is the serializable form of a pending, not-yet-resolved
+// request: just enough to re-enqueue it after a restart.
+type PersistedRequest struct {
+	URL      string `json:"url"`
+	PopOrder int    `json:"pop_order"`
+}
+
+// PersistedState is what SaveState writes and LoadState reads back.
+type PersistedState struct {
+	Pending  []PersistedRequest `json:"pending"`
+	Resolved []PersistedRequest `json:"resolved"`
+}
+
+// SaveState writes the Client's pending (unresolved) URLs, as well as the
+// original URLs of files sitting resolved in ResolvedQueue awaiting
+// retrieval (e.g. because retrievers are paused or busy), to path in queue
+// order, so a long-running daemon can pick up where it left off after a
+// restart. Resolved files come back as pending on LoadState and are
+// re-resolved rather than restored directly into ResolvedQueue; there's no
+// way to persist an api.File itself, only the URL that produced it.
+func (d *Client) SaveState(path string) error {
+	reqs := d.resolverQueue.snapshot()
+	resolved := d.ResolvedQueue.snapshot()
+	state := PersistedState{
+		Pending:  make([]PersistedRequest, len(reqs)),
+		Resolved: make([]PersistedRequest, len(resolved)),
+	}
+	for i, r := range reqs {
+		state.Pending[i] = PersistedRequest{URL: r.u.String(), PopOrder: i}
+	}
+	for i, r := range resolved {
+		state.Resolved[i] = PersistedRequest{URL: r.file.OriginalURL().String(), PopOrder: i}
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadState reads a PersistedState written by SaveState and re-enqueues its
+// pending and resolved URLs onto the Client. It waits for the requests to
+// actually land in resolverQueue before returning, unlike AddURLs (which
+// enqueues from a fire-and-forget goroutine): a caller re-reading the queue
+// right after LoadState, such as a restart handler, must see the restored
+// work.
+func (d *Client) LoadState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var state PersistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	urls := make([]*url.URL, 0, len(state.Pending)+len(state.Resolved))
+	for _, group := range [][]PersistedRequest{state.Pending, state.Resolved} {
+		// Priority below is assigned by slice index, so restore each group's
+		// original queue order from PopOrder rather than trusting the JSON
+		// array's order.
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].PopOrder < group[j].PopOrder
+		})
+		for _, p := range group {
+			u, err := url.Parse(p.URL)
+			if err != nil {
+				return err
+			}
+			urls = append(urls, u)
+		}
+	}
+	wg := new(sync.WaitGroup)
+	wg.Add(len(urls))
+	requests := make([]*request, len(urls))
+	for i, u := range urls {
+		requests[i] = rootRequest(u, wg, i)
+	}
+	<-d.resolverQueue.enqueueAll(requests)
+	return nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/uget/uget/core/api"
+)
+
+// fakeProvider names every file it produces "fake", the only bit of
+// api.Provider a test needs.
+type fakeProvider struct{}
+
+func (fakeProvider) Name() string { return "fake" }
+
+// fakeAPIFile is a minimal api.File, enough to wrap with online() and put in
+// ResolvedQueue.
+type fakeAPIFile struct {
+	u *url.URL
+}
+
+func (f *fakeAPIFile) Provider() api.Provider            { return fakeProvider{} }
+func (f *fakeAPIFile) Name() string                      { return "file" }
+func (f *fakeAPIFile) URL() *url.URL                     { return f.u }
+func (f *fakeAPIFile) Size() int64                       { return 0 }
+func (f *fakeAPIFile) Checksum() ([]byte, string, error) { return nil, "", nil }
+
+// TestSaveLoadStateRoundTrip exercises SaveState/LoadState across both
+// queues: a URL still awaiting resolution in resolverQueue, and a File
+// already resolved and sitting in ResolvedQueue awaiting retrieval. Both
+// must come back as pending URLs on LoadState.
+func TestSaveLoadStateRoundTrip(t *testing.T) {
+	d := NewClientWith(0)
+
+	pendingURL, _ := url.Parse("fake://pending")
+	<-d.resolverQueue.enqueue(rootRequest(pendingURL, new(sync.WaitGroup), 0))
+
+	resolvedURL, _ := url.Parse("fake://resolved")
+	f := online(&fakeAPIFile{u: resolvedURL}, resolvedURL, func() {})
+	<-d.ResolvedQueue.enqueue(resolvedRequest(f, new(sync.WaitGroup), 0))
+
+	path := t.TempDir() + "/uget-state-test.json"
+	if err := d.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	defer os.Remove(path)
+
+	reloaded := NewClientWith(0)
+	if err := reloaded.LoadState(path); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+
+	got := map[string]bool{}
+	for _, req := range reloaded.resolverQueue.snapshot() {
+		got[req.u.String()] = true
+	}
+	for _, want := range []string{pendingURL.String(), resolvedURL.String()} {
+		if !got[want] {
+			t.Errorf("LoadState: %q missing from resolverQueue, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/uget/uget/core/api"
+)
+
+// Provider identifies a download source (e.g. "b2") and is registered once,
+// typically from its package's init(), so Client can offer it to resolvers.
+type Provider interface {
+	Name() string
+}
+
+// Providers is a registry of Provider implementations.
+type Providers []Provider
+
+// FindProvider returns the first Provider matching pred, or nil if none does.
+func (ps Providers) FindProvider(pred func(Provider) bool) Provider {
+	for _, p := range ps {
+		if pred(p) {
+			return p
+		}
+	}
+	return nil
+}
+
+var registeredProviders Providers
+
+// RegisterProvider adds p to the set returned by RegisteredProviders. It is
+// meant to be called from a provider package's init() function.
+func RegisterProvider(p Provider) {
+	registeredProviders = append(registeredProviders, p)
+}
+
+// RegisteredProviders returns every Provider registered so far.
+func RegisteredProviders() Providers {
+	return registeredProviders
+}
+
+// Resolver turns a URL it recognizes into an api.File.
+type Resolver interface {
+	Provider
+	// CanResolve reports whether this Resolver's provider handles u.
+	CanResolve(u *url.URL) bool
+	// Resolve fetches metadata (size, checksum, ...) for u.
+	Resolve(u *url.URL) (api.File, error)
+}
+
+// Retriever downloads the bytes of a File a Resolver produced.
+type Retriever interface {
+	Provider
+	// Retrieve streams f's contents to w, resuming from byte `offset` if the
+	// Client is continuing a partial download. A Retriever that issues its
+	// own *http.Request(s) should build them with ctx (e.g.
+	// req.WithContext(ctx)) so BandwidthMeter can attribute their bytes to
+	// the *Download this call is for, not just the Client-wide aggregate.
+	Retrieve(ctx context.Context, f api.File, w io.Writer, offset int64) error
+}
+
+// Configured is implemented by Providers that need account credentials,
+// supplied via Client.Use.
+type Configured interface {
+	Configure(*Config)
+}
+
+// Config is handed to a Provider's Configure method with the Accounts a user
+// registered for it.
+type Config struct {
+	Accounts []Account
+	// HTTPClient is the Client's instrumented *http.Client (BandwidthMeter,
+	// and a FaultInjector if one was set). A Provider should issue all its
+	// requests through it rather than a private http.Client, so its traffic
+	// is accounted for and can be exercised by the fault injector.
+	HTTPClient *http.Client
+}
+
+// Account is a set of provider-specific credentials registered via Client.Use.
+// Implementations are typically a pointer to a provider-defined struct.
+type Account interface{}
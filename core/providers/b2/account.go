@@ -0,0 +1,11 @@
+package b2
+
+// Account holds Backblaze B2 credentials: either a master application key
+// (full account access) or a scoped application key restricted by B2 to a
+// single bucket, name prefix, and set of capabilities. The restrictions, if
+// any, come back from b2_authorize_account and are not part of Account
+// itself.
+type Account struct {
+	AccountID      string `json:"account_id"`
+	ApplicationKey string `json:"application_key"`
+}
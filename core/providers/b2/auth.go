@@ -0,0 +1,129 @@
+package b2
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const authorizeURL = "https://api.backblazeb2.com/b2api/v2/b2_authorize_account"
+
+// allowed mirrors the restrictions b2_authorize_account returns for a scoped
+// application key; zero value for a master key (unrestricted). BucketName is
+// what we actually compare a b2://bucket/path URL's bucket against; BucketID
+// is kept alongside it (as B2 returns both) but we have no bucket-name-to-id
+// lookup, so it's only informational.
+type allowed struct {
+	BucketID     string   `json:"bucketId"`
+	BucketName   string   `json:"bucketName"`
+	NamePrefix   string   `json:"namePrefix"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type authorizeResponse struct {
+	AuthorizationToken string  `json:"authorizationToken"`
+	APIURL             string  `json:"apiUrl"`
+	DownloadURL        string  `json:"downloadUrl"`
+	Allowed            allowed `json:"allowed"`
+}
+
+// session caches a b2_authorize_account result for an Account, re-authorizing
+// on demand (e.g. after a 401, since tokens expire).
+type session struct {
+	account *Account
+
+	mu                 sync.Mutex
+	authorizationToken string
+	downloadURL        string
+	allowed            allowed
+}
+
+func newSession(acc *Account) *session {
+	return &session{account: acc}
+}
+
+// token returns a cached authorization token, authorizing for the first time
+// if necessary.
+func (s *session) token(client *http.Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.authorizationToken != "" {
+		return s.authorizationToken, nil
+	}
+	return s.authorizeLocked(client)
+}
+
+// refresh discards the cached token and re-authorizes. Called after a 401.
+func (s *session) refresh(client *http.Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authorizeLocked(client)
+}
+
+// endpoint returns the currently cached download host, authorized by the
+// last token()/refresh() call. Goes through the same lock authorizeLocked
+// writes under, since a concurrent 401-triggered refresh() can change it.
+func (s *session) endpoint() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloadURL
+}
+
+// checkAccess reports an error if this session's application key is scoped
+// to a bucket, name prefix, or capability set that doesn't cover bucket/name,
+// rather than letting the restriction be silently ignored. A zero allowed
+// (master key, or not yet authorized) permits everything.
+func (s *session) checkAccess(bucket, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.allowed
+	if len(a.Capabilities) > 0 && !hasCapability(a.Capabilities, "readFiles") {
+		return fmt.Errorf("b2: application key lacks the readFiles capability")
+	}
+	if a.BucketName != "" && a.BucketName != bucket {
+		return fmt.Errorf("b2: application key is restricted to bucket %q, not %q", a.BucketName, bucket)
+	}
+	if a.NamePrefix != "" && !strings.HasPrefix(name, a.NamePrefix) {
+		return fmt.Errorf("b2: application key is restricted to name prefix %q, which %q doesn't match", a.NamePrefix, name)
+	}
+	return nil
+}
+
+func hasCapability(capabilities []string, want string) bool {
+	for _, c := range capabilities {
+		if c == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *session) authorizeLocked(client *http.Client) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, authorizeURL, nil)
+	if err != nil {
+		return "", err
+	}
+	creds := base64.StdEncoding.EncodeToString([]byte(s.account.AccountID + ":" + s.account.ApplicationKey))
+	req.Header.Set("Authorization", "Basic "+creds)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{resp.StatusCode, fmt.Sprintf("b2_authorize_account: %s", resp.Status), 0}
+	}
+
+	var body authorizeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	s.authorizationToken = body.AuthorizationToken
+	s.downloadURL = body.DownloadURL
+	s.allowed = body.Allowed
+	return s.authorizationToken, nil
+}
@@ -0,0 +1,55 @@
+// Package b2 implements a core.Provider for Backblaze B2, supporting both
+// master application keys and scoped application keys.
+package b2
+
+import (
+	"net/http"
+
+	"github.com/uget/uget/core"
+)
+
+// Provider implements core.Provider, core.Resolver, core.Retriever and
+// core.Configured for Backblaze B2.
+type Provider struct {
+	client  *http.Client
+	session *session
+}
+
+var (
+	_ core.Provider   = new(Provider)
+	_ core.Resolver   = new(Provider)
+	_ core.Retriever  = new(Provider)
+	_ core.Configured = new(Provider)
+)
+
+func init() {
+	core.RegisterProvider(New())
+}
+
+// New creates an unconfigured B2 Provider, using http.DefaultClient until
+// Configure supplies the owning Client's instrumented one.
+func New() *Provider {
+	return &Provider{client: http.DefaultClient}
+}
+
+// Name implements core.Provider.
+func (p *Provider) Name() string {
+	return "b2"
+}
+
+// Configure implements core.Configured. It adopts the Client's instrumented
+// *http.Client, so B2 traffic counts toward bandwidth accounting and can be
+// exercised by a FaultInjector, same as any other provider's. Only the first
+// Account is used; B2 has no notion of multiple simultaneous accounts for a
+// single client.
+func (p *Provider) Configure(cfg *core.Config) {
+	if cfg.HTTPClient != nil {
+		p.client = cfg.HTTPClient
+	}
+	if len(cfg.Accounts) == 0 {
+		return
+	}
+	if acc, ok := cfg.Accounts[0].(*Account); ok {
+		p.session = newSession(acc)
+	}
+}
@@ -0,0 +1,37 @@
+package b2
+
+import (
+	"encoding/hex"
+	"net/url"
+
+	"github.com/uget/uget/core/api"
+)
+
+// file is the api.File a Provider.Resolve produces.
+type file struct {
+	provider *Provider
+	u        *url.URL
+	name     string
+	size     int64
+	sha1     string
+}
+
+var _ api.File = new(file)
+
+func (f *file) Provider() api.Provider { return f.provider }
+func (f *file) Name() string           { return f.name }
+func (f *file) URL() *url.URL          { return f.u }
+func (f *file) Size() int64            { return f.size }
+
+// Checksum implements api.File, surfacing the SHA1 B2 returns for every
+// uploaded file.
+func (f *file) Checksum() ([]byte, string, error) {
+	if f.sha1 == "" || f.sha1 == "none" {
+		return nil, "", nil
+	}
+	sum, err := hex.DecodeString(f.sha1)
+	if err != nil {
+		return nil, "", err
+	}
+	return sum, "SHA1", nil
+}
@@ -0,0 +1,142 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/uget/uget/core/api"
+)
+
+var friendlyHostPattern = regexp.MustCompile(`^f\d+\.backblazeb2\.com$`)
+
+// parseURL extracts the bucket and file name b2_download_file_by_name needs
+// from either a b2://bucket/path URL or a friendly
+// https://f0xx.backblazeb2.com/file/bucket/path URL.
+func parseURL(u *url.URL) (bucket, name string, ok bool) {
+	if u.Scheme == "b2" {
+		name = strings.TrimPrefix(u.Path, "/")
+		return u.Host, name, u.Host != "" && name != ""
+	}
+	if friendlyHostPattern.MatchString(u.Host) {
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 3)
+		if len(parts) == 3 && parts[0] == "file" {
+			return parts[1], parts[2], true
+		}
+	}
+	return "", "", false
+}
+
+// CanResolve implements core.Resolver.
+func (p *Provider) CanResolve(u *url.URL) bool {
+	_, _, ok := parseURL(u)
+	return ok
+}
+
+// Resolve implements core.Resolver. It issues a 1-byte range download
+// request to learn the file's size and SHA1 checksum without fetching its
+// whole body.
+func (p *Provider) Resolve(u *url.URL) (api.File, error) {
+	bucket, name, ok := parseURL(u)
+	if !ok {
+		return nil, fmt.Errorf("b2: not a b2 url: %v", u)
+	}
+
+	resp, err := p.downloadRequest(context.Background(), bucket, name, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var size int64 = api.FileSizeUnknown
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		if i := strings.LastIndex(cr, "/"); i >= 0 {
+			if n, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				size = n
+			}
+		}
+	} else if cl := resp.Header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+			size = n
+		}
+	}
+
+	return &file{
+		provider: p,
+		u:        u,
+		name:     name,
+		size:     size,
+		sha1:     resp.Header.Get("X-Bz-Content-Sha1"),
+	}, nil
+}
+
+// downloadRequest issues a b2_download_file_by_name request for bucket/name,
+// requesting the byte range [from, to] (to < 0 means "to EOF"). It refreshes
+// the session's auth token on a 401, and turns a 503 into a retryable
+// statusError carrying any Retry-After B2 sent, so the queue's own
+// requeue-with-backoff can honor it without blocking this (single) resolver
+// goroutine on a sleep. ctx carries the *core.Download this request belongs
+// to, if any (Retrieve has one, Resolve doesn't), so the Client's
+// BandwidthMeter can attribute its bytes accordingly.
+func (p *Provider) downloadRequest(ctx context.Context, bucket, name string, from, to int64) (*http.Response, error) {
+	if p.session == nil {
+		return nil, fmt.Errorf("b2: no account configured")
+	}
+
+	rng := fmt.Sprintf("bytes=%d-", from)
+	if to >= 0 {
+		rng = fmt.Sprintf("bytes=%d-%d", from, to)
+	}
+
+	// endpoint is derived fresh from session.endpoint() on every call, since
+	// it's only populated once token()/refresh() has actually authorized
+	// (the very first request of a process has no endpoint yet until then),
+	// and refresh() can hand back a different downloadURL later on.
+	do := func(token string) (*http.Response, error) {
+		endpoint := fmt.Sprintf("%s/file/%s/%s", p.session.endpoint(), bucket, name)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Range", rng)
+		return p.client.Do(req)
+	}
+
+	token, err := p.session.token(p.client)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.session.checkAccess(bucket, name); err != nil {
+		return nil, err
+	}
+	resp, err := do(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		if token, err = p.session.refresh(p.client); err != nil {
+			return nil, err
+		}
+		if resp, err = do(token); err != nil {
+			return nil, err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return resp, nil
+	case http.StatusServiceUnavailable:
+		defer resp.Body.Close()
+		return nil, &statusError{resp.StatusCode, fmt.Sprintf("b2_download_file_by_name: %s", resp.Status), retryAfter(resp.Header)}
+	default:
+		defer resp.Body.Close()
+		return nil, &statusError{resp.StatusCode, fmt.Sprintf("b2_download_file_by_name: %s", resp.Status), 0}
+	}
+}
@@ -0,0 +1,97 @@
+package b2
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// TestResolveAndRetrieve drives Resolve and Retrieve against an httptest
+// server standing in for B2's download host, using an already-authorized
+// session so the test doesn't depend on the real b2_authorize_account
+// endpoint. It guards the endpoint/auth-header plumbing downloadRequest
+// shares between both.
+func TestResolveAndRetrieve(t *testing.T) {
+	const body = "hello world"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "test-token" {
+			t.Errorf("Authorization header = %q, want %q", got, "test-token")
+		}
+		if r.URL.Path != "/file/bucket/dir/file.txt" {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		w.Header().Set("X-Bz-Content-Sha1", "none")
+		if r.Header.Get("Range") == "bytes=0-0" {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-0/%d", len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(body[:1]))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	p := &Provider{
+		client: srv.Client(),
+		session: &session{
+			authorizationToken: "test-token",
+			downloadURL:        srv.URL,
+		},
+	}
+
+	u, err := url.Parse("b2://bucket/dir/file.txt")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	f, err := p.Resolve(u)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if f.Size() != int64(len(body)) {
+		t.Errorf("Resolve: Size() = %d, want %d", f.Size(), len(body))
+	}
+
+	var buf bytes.Buffer
+	if err := p.Retrieve(context.Background(), f, &buf, 0); err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if buf.String() != body {
+		t.Errorf("Retrieve() wrote %q, want %q", buf.String(), body)
+	}
+}
+
+// TestSessionCheckAccess covers the restrictions a scoped application key's
+// allowed object can carry, each of which must reject a request it doesn't
+// cover.
+func TestSessionCheckAccess(t *testing.T) {
+	cases := []struct {
+		name    string
+		allowed allowed
+		bucket  string
+		file    string
+		wantErr bool
+	}{
+		{"unrestricted master key", allowed{}, "any-bucket", "any/name", false},
+		{"matching bucket and prefix", allowed{BucketName: "b", NamePrefix: "dir/", Capabilities: []string{"readFiles"}}, "b", "dir/file.txt", false},
+		{"wrong bucket", allowed{BucketName: "b"}, "other", "file.txt", true},
+		{"prefix mismatch", allowed{NamePrefix: "dir/"}, "b", "other/file.txt", true},
+		{"missing readFiles capability", allowed{Capabilities: []string{"writeFiles"}}, "b", "file.txt", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := &session{allowed: c.allowed}
+			err := s.checkAccess(c.bucket, c.file)
+			if c.wantErr && err == nil {
+				t.Errorf("checkAccess(%q, %q) = nil, want error", c.bucket, c.file)
+			}
+			if !c.wantErr && err != nil {
+				t.Errorf("checkAccess(%q, %q) = %v, want nil", c.bucket, c.file, err)
+			}
+		})
+	}
+}
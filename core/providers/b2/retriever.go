@@ -0,0 +1,33 @@
+package b2
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/uget/uget/core/api"
+)
+
+// Retrieve implements core.Retriever, streaming f's contents to w starting
+// at `offset` (continuing a partial download), via B2's Range support. It
+// passes ctx through to downloadRequest so the Client's BandwidthMeter can
+// attribute this request's bytes to the *core.Download ctx carries.
+func (p *Provider) Retrieve(ctx context.Context, f api.File, w io.Writer, offset int64) error {
+	bf, ok := f.(*file)
+	if !ok {
+		return fmt.Errorf("b2: not a b2 file: %v", f)
+	}
+	bucket, name, ok := parseURL(bf.u)
+	if !ok {
+		return fmt.Errorf("b2: not a b2 url: %v", bf.u)
+	}
+
+	resp, err := p.downloadRequest(ctx, bucket, name, offset, -1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
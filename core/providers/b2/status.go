@@ -0,0 +1,43 @@
+package b2
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusError is returned when B2 responds with an unexpected status. It
+// exposes StatusCode so core.RetryPolicy's default Retryable predicate
+// recognizes 503s and 429s without b2 needing to know about core at all.
+type statusError struct {
+	status  int
+	message string
+	// after is the server-specified delay (e.g. a Retry-After header)
+	// before this request should be retried, or 0 if none was given.
+	after time.Duration
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// StatusCode is read by core.RetryPolicy's default Retryable predicate.
+func (e *statusError) StatusCode() int {
+	return e.status
+}
+
+// RetryAfter is read by core.Client.retry to raise its computed backoff to
+// at least the server-specified delay.
+func (e *statusError) RetryAfter() time.Duration {
+	return e.after
+}
+
+// retryAfter parses B2's Retry-After header (seconds), returning 0 if absent
+// or unparseable.
+func retryAfter(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
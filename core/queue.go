@@ -2,6 +2,7 @@ package core
 
 import (
 	"container/heap"
+	"time"
 
 	"github.com/uget/uget/utils"
 )
@@ -19,13 +20,31 @@ type queue struct {
 	*pQueue
 	get       chan File
 	getAll    chan []*request
+	getReq    chan *request
 	finalized bool
+	paused    bool
+	done      chan struct{}
 }
 
 func (q *queue) Dequeue() <-chan File {
 	return q.get
 }
 
+// dequeueRequests exposes the raw *request behind each queued item, as it
+// becomes ready. workResolve needs it for resolverQueue (there is no File
+// yet to hand out), and both workResolve and workRetrieve need it to pass
+// the *request through to Client.retry on a retryable failure.
+func (q *queue) dequeueRequests() <-chan *request {
+	return q.getReq
+}
+
+// Done returns a channel that's closed once this queue has been finalized
+// and its dispatch loop has exited. Items still queued at that point are not
+// drained; they are left unserved.
+func (q *queue) Done() <-chan struct{} {
+	return q.done
+}
+
 func (q *queue) List() []File {
 	var pq pQueue
 	<-q.Job(func() {
@@ -72,29 +91,103 @@ func (q *queue) Remove(id string) <-chan File {
 	return fchan
 }
 
+// Find returns the File with the specified ID without removing it from the
+// queue, or nil if there is none.
+func (q *queue) Find(id string) File {
+	var found File
+	<-q.Job(func() {
+		for _, item := range *q.pQueue {
+			if item.file != nil && item.file.ID() == id {
+				found = item.file
+				return
+			}
+		}
+	})
+	return found
+}
+
+// snapshot returns a copy of the requests currently queued, in priority order.
+func (q *queue) snapshot() []*request {
+	var pq pQueue
+	<-q.Job(func() {
+		pq = make(pQueue, q.Len())
+		copy(pq, *q.pQueue)
+	})
+	reqs := make([]*request, pq.Len())
+	i := 0
+	for pq.Len() > 0 {
+		reqs[i] = pq.peek()
+		heap.Pop(&pq)
+		i++
+	}
+	return reqs
+}
+
+// requeue puts req back on the queue to be retried after `wait`, bumping its
+// attempt count. It is called by the resolve/retrieve workers when a
+// RetryPolicy deems an error retryable.
+func (q *queue) requeue(req *request, wait time.Duration) <-chan struct{} {
+	return q.Job(func() {
+		req.attempts++
+		req.availableAt = time.Now().Add(wait)
+		heap.Push(q, req)
+	})
+}
+
 func newQueue() *queue {
 	pq := make(pQueue, 0, 31)
 	get := make(chan File)
 	getAll := make(chan []*request)
+	getReq := make(chan *request)
 	q := &queue{
 		utils.NewJobber(),
 		&pq,
 		get,
 		getAll,
+		getReq,
+		false,
 		false,
+		make(chan struct{}),
 	}
 	go q.dispatch()
 	return q
 }
 
-// Finalize stops this queue gracefully,
-// making it close all channels once emptied.
+// Finalize marks this queue as finalized; its dispatch loop will close all
+// of its channels on its next iteration, rather than continuing to serve
+// whatever is still queued. A request a worker already popped is unaffected
+// and finishes normally.
 func (q *queue) Finalize() <-chan struct{} {
 	return q.Job(func() {
 		q.finalized = true
 	})
 }
 
+// Pause stops this queue from handing ready items to workers until Resume is
+// called. Items already popped by a worker are unaffected; items still in
+// the queue stay there, untouched.
+func (q *queue) Pause() <-chan struct{} {
+	return q.Job(func() {
+		q.paused = true
+	})
+}
+
+// Resume undoes a prior Pause.
+func (q *queue) Resume() <-chan struct{} {
+	return q.Job(func() {
+		q.paused = false
+	})
+}
+
+// isPaused reports whether Pause has been called without a matching Resume.
+func (q *queue) isPaused() bool {
+	var paused bool
+	<-q.Job(func() {
+		paused = q.paused
+	})
+	return paused
+}
+
 func (q *queue) enqueue(req *request) <-chan struct{} {
 	return q.Job(func() {
 		heap.Push(q, req)
@@ -111,29 +204,54 @@ func (q *queue) enqueueAll(reqs []*request) <-chan struct{} {
 
 // == private methods, not to be used from outside ==
 
+// hiddenWait is polled while every queued item is backed off, so that a
+// newly enqueued job or a `Job` closure can still interrupt the wait.
+const hiddenWait = 50 * time.Millisecond
+
 func (q *queue) dispatch() {
 	order := 0
 	for {
-		if q.Len() > 0 {
-			if q.peek().resolved() {
-				q.peek().file.setPopOrder(order)
+		if q.finalized {
+			// Close immediately rather than draining whatever is still
+			// queued: a caller finalizing this queue is expected to have
+			// already persisted anything pending (see Client.SaveState), so
+			// draining it here would process it a second time. A request
+			// already popped by a worker is unaffected; it just finishes
+			// its current item and then sees this channel closed.
+			close(q.get)
+			close(q.getAll)
+			close(q.getReq)
+			close(q.done)
+			return
+		} else if i := q.pQueue.readyIndex(); !q.paused && i >= 0 {
+			req := (*q.pQueue)[i]
+			if req.resolved() {
+				req.file.setPopOrder(order)
 			}
 			select {
 			case q.getAll <- *q.pQueue:
 				pq := make(pQueue, 0)
 				q.pQueue = &pq
-			case q.get <- q.peek().file:
-				// fmt.Printf("q#pop, prio %v, url %v\n", q.peek().prio, q.peek().u)
-				heap.Pop(q)
+			case q.get <- req.file:
+				heap.Remove(q, i)
+				order++
+			case q.getReq <- req:
+				heap.Remove(q, i)
 				order++
 			case job := <-q.JobQueue:
 				job.Work()
 				close(job.Done)
 			}
-		} else if q.finalized {
-			close(q.get)
-			close(q.getAll)
-			return
+		} else if !q.paused && q.Len() > 0 {
+			// every queued item is backed off after a retryable failure; wait
+			// it out without blocking Jobs or a newly-ready item from being
+			// serviced.
+			select {
+			case <-time.After(hiddenWait):
+			case job := <-q.JobQueue:
+				job.Work()
+				close(job.Done)
+			}
 		} else {
 			job := <-q.JobQueue
 			job.Work()
@@ -148,6 +266,11 @@ func (pq pQueue) Len() int {
 	return len(pq)
 }
 
+// Less orders purely by priority. It deliberately does not also factor in
+// hidden()/ready(), which is time-varying and would go stale between heap
+// operations (an item pushed while hidden can become ready, or vice versa,
+// without anything re-sorting the heap); dispatch finds the best ready item
+// itself via readyIndex instead of trusting peek().
 func (pq pQueue) Less(i, j int) bool {
 	return pq[i].less(pq[j])
 }
@@ -168,3 +291,18 @@ func (pq *pQueue) Pop() interface{} {
 func (pq pQueue) peek() *request {
 	return pq[0]
 }
+
+// readyIndex returns the index of the ready item with the lowest priority,
+// or -1 if none of the queued items are ready yet. Unlike peek(), which only
+// reflects the heap's Push-time ordering, this scans every item so a request
+// that has become ready since it was pushed is never starved behind one that
+// is still hidden but happened to land nearer the root.
+func (pq pQueue) readyIndex() int {
+	best := -1
+	for i, req := range pq {
+		if req.ready() && (best == -1 || req.less(pq[best])) {
+			best = i
+		}
+	}
+	return best
+}
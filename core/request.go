@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// request is the unit of work moving through a queue: either a url.URL
+// awaiting resolution, or a resolved File awaiting retrieval.
+type request struct {
+	u    *url.URL
+	wg   *sync.WaitGroup
+	prio int
+	file File
+
+	attempts    int
+	availableAt time.Time
+}
+
+// rootRequest creates the initial, unresolved request for a URL passed to AddURLs.
+func rootRequest(u *url.URL, wg *sync.WaitGroup, prio int) *request {
+	return &request{u: u, wg: wg, prio: prio}
+}
+
+// resolvedRequest creates a request for a File that is ready to be retrieved.
+func resolvedRequest(f File, wg *sync.WaitGroup, prio int) *request {
+	return &request{u: f.URL(), wg: wg, prio: prio, file: f}
+}
+
+func (r *request) resolved() bool {
+	return r.file != nil
+}
+
+// hidden reports whether this request is backed off after a retryable
+// failure and should not be served to workers yet.
+func (r *request) hidden() bool {
+	return r.availableAt.After(time.Now())
+}
+
+// ready is the inverse of hidden, phrased for call sites that dispatch work.
+func (r *request) ready() bool {
+	return !r.hidden()
+}
+
+func (r *request) less(other *request) bool {
+	return r.prio < other.prio
+}
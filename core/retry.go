@@ -0,0 +1,109 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how a Client retries a resolve or retrieve that fails
+// with a transient error, instead of failing the download outright.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries (including the first),
+	// after which the final error is reported: through OnError for a
+	// retrieve, through OnResolve for a resolve.
+	MaxAttempts int
+	// InitialInterval is the delay before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval caps the computed backoff delay.
+	MaxInterval time.Duration
+	// Multiplier is applied to the interval after every attempt.
+	Multiplier float64
+	// RandomizationFactor jitters the computed interval by +/- this fraction.
+	RandomizationFactor float64
+	// Retryable decides whether an error should be retried at all.
+	// Defaults to network errors and 5xx / 429 responses.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy is used by NewClient(With) unless overridden.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:         5,
+	InitialInterval:     500 * time.Millisecond,
+	MaxInterval:         30 * time.Second,
+	Multiplier:          2,
+	RandomizationFactor: 0.5,
+	Retryable:           defaultRetryable,
+}
+
+// statusCoder is implemented by errors that carry the HTTP status code that
+// caused them (e.g. a provider's "unexpected status" error type).
+type statusCoder interface {
+	StatusCode() int
+}
+
+// temporary is implemented by net.Error and friends.
+type temporary interface {
+	Temporary() bool
+}
+
+// retryAfterer is implemented by errors that know the server-specified delay
+// before retrying (e.g. an HTTP Retry-After header), letting it raise the
+// policy's own computed backoff for this attempt instead of being honored
+// separately.
+type retryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+func defaultRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if sc, ok := err.(statusCoder); ok {
+		status := sc.StatusCode()
+		return status == http.StatusTooManyRequests || status >= 500
+	}
+	// Any net.Error counts, including the *url.Error http.Client.Do returns,
+	// not only those whose Temporary() reports true.
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return false
+}
+
+// next computes the delay before the given attempt (1-indexed: the delay
+// before retry #1, retry #2, ...), jittered by +/- RandomizationFactor.
+func (p RetryPolicy) next(attempt int) time.Duration {
+	interval := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt-1))
+	if max := float64(p.MaxInterval); p.MaxInterval > 0 && interval > max {
+		interval = max
+	}
+	delta := interval * p.RandomizationFactor
+	lo, hi := interval-delta, interval+delta
+	if hi <= lo {
+		return time.Duration(interval)
+	}
+	return time.Duration(lo + rand.Float64()*(hi-lo))
+}
+
+// retry is called by the resolve/retrieve workers when `err` occurred while
+// working on req. It reports whether the request was requeued (in which case
+// the caller must not also report the error), emitting OnRetry as it does so.
+func (d *Client) retry(q *queue, req *request, f File, err error) bool {
+	policy := d.RetryPolicy
+	if policy.Retryable == nil || !policy.Retryable(err) || req.attempts+1 >= policy.MaxAttempts {
+		return false
+	}
+	attempt := req.attempts + 1
+	wait := policy.next(attempt)
+	if ra, ok := err.(retryAfterer); ok {
+		if after := ra.RetryAfter(); after > wait {
+			wait = after
+		}
+	}
+	d.Emit(eRetry, f, attempt, wait, err)
+	<-q.requeue(req, wait)
+	return true
+}
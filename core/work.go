@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// workResolve resolves queued URLs into Files, one at a time, and hands the
+// result to ResolvedQueue for retrieval. A retryable resolve error is
+// requeued via RetryPolicy instead of failing the download outright.
+func (d *Client) workResolve() {
+	for req := range d.resolverQueue.dequeueRequests() {
+		d.resolveOne(req)
+	}
+}
+
+func (d *Client) resolveOne(req *request) {
+	resolver := d.Providers.FindProvider(func(p Provider) bool {
+		r, ok := p.(Resolver)
+		return ok && r.CanResolve(req.u)
+	})
+	if resolver == nil {
+		d.Emit(eDeadend, req.u)
+		req.wg.Done()
+		return
+	}
+
+	apiFile, err := resolver.(Resolver).Resolve(req.u)
+	if err != nil {
+		if d.retry(d.resolverQueue, req, nil, err) {
+			return
+		}
+		d.Emit(eResolve, req.u, nil, err)
+		req.wg.Done()
+		return
+	}
+
+	wg := req.wg
+	f := online(apiFile, req.u, wg.Done)
+	d.Emit(eResolve, req.u, f, nil)
+	<-d.ResolvedQueue.enqueue(resolvedRequest(f, req.wg, req.prio))
+}
+
+// workRetrieve retrieves resolved Files, one at a time. A retryable retrieve
+// error is requeued via RetryPolicy instead of failing the download outright.
+func (d *Client) workRetrieve() {
+	for req := range d.ResolvedQueue.dequeueRequests() {
+		d.retrieveOne(req)
+	}
+}
+
+func (d *Client) retrieveOne(req *request) {
+	f := req.file
+	retriever := d.Providers.FindProvider(func(p Provider) bool {
+		return p.Name() == f.Provider().Name()
+	})
+	r, ok := retriever.(Retriever)
+	if !ok {
+		d.Emit(eError, f, fmt.Errorf("uget: no retriever registered for provider %q", f.Provider().Name()))
+		f.done()
+		return
+	}
+
+	dst, offset, err := d.destination(f)
+	if err != nil {
+		d.Emit(eError, f, err)
+		f.done()
+		return
+	}
+	defer dst.Close()
+
+	if d.Skip && !f.LengthUnknown() && offset >= f.Size() {
+		d.Emit(eSkip, f)
+		f.done()
+		return
+	}
+
+	if d.dryRun("retrieve %v to %v", f.URL(), dst.Name()) {
+		f.done()
+		return
+	}
+
+	dl := &Download{File: f, Offset: offset}
+	d.Emit(eDownload, dl)
+
+	ctx := withDownload(context.Background(), dl)
+	if err := r.Retrieve(ctx, f, dst, offset); err != nil {
+		if d.retry(d.ResolvedQueue, req, f, err) {
+			return
+		}
+		d.Emit(eError, f, err)
+		f.done()
+		return
+	}
+
+	f.done()
+}
+
+// destination opens the local file f is retrieved into, returning the
+// offset retrieval should resume at. With NoContinue, it always truncates
+// and starts at 0; otherwise it resumes from whatever was already written.
+func (d *Client) destination(f File) (*os.File, int64, error) {
+	path := filepath.Join(d.Directory, f.Name())
+	if d.NoContinue {
+		file, err := os.Create(path)
+		return file, 0, err
+	}
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	return file, offset, err
+}
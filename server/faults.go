@@ -0,0 +1,44 @@
+// +build debug
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Unknwon/macaron"
+	"github.com/uget/uget/core"
+)
+
+// registerFaultRoutes wires the fault-injection profile toggle into m. Only
+// built into `debug` builds, so integration tests can switch fault profiles
+// at runtime without exposing the knob in production. Gated behind
+// requireAuth like the other control-plane routes: it can redirect all of
+// the server's live traffic through an injected-failure profile, and
+// loadFaultProfile reads whatever path it's given off disk.
+func registerFaultRoutes(m *macaron.Macaron) {
+	m.Post("/debug/faults", requireAuth, loadFaultProfile)
+	m.Delete("/debug/faults", requireAuth, clearFaultProfile)
+}
+
+func loadFaultProfile(c *macaron.Context) {
+	var body struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(c.Req.Body().ReadCloser()).Decode(&body); err != nil {
+		c.Render.Error(http.StatusBadRequest, "Invalid JSON.")
+		return
+	}
+	fi, err := core.LoadFaultProfile(body.Path)
+	if err != nil {
+		c.Render.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+	currentDownloader().SetFaultInjector(fi)
+	c.Status(http.StatusNoContent)
+}
+
+func clearFaultProfile(c *macaron.Context) {
+	currentDownloader().SetFaultInjector(nil)
+	c.Status(http.StatusNoContent)
+}
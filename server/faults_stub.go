@@ -0,0 +1,8 @@
+// +build !debug
+
+package server
+
+import "github.com/Unknwon/macaron"
+
+// registerFaultRoutes is a no-op outside of `debug` builds.
+func registerFaultRoutes(m *macaron.Macaron) {}
@@ -1,10 +1,13 @@
 package server
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -19,7 +22,48 @@ type Server struct {
 	StartedAt time.Time `json:"started_at"`
 }
 
-var downloader = core.NewClient()
+// queueStateFile is where restart persists the downloader's pending queue and
+// where Run loads it from at startup, so a long-running daemon doesn't lose
+// work when its downloader is restarted.
+const queueStateFile = "uget-queue-state.json"
+
+// downloaderMu guards downloader, which restart swaps out for a fresh
+// Client while other handlers may be reading it concurrently.
+var (
+	downloaderMu sync.RWMutex
+	downloader   = core.NewClient()
+)
+
+// currentDownloader returns the Client currently in use.
+func currentDownloader() *core.Client {
+	downloaderMu.RLock()
+	defer downloaderMu.RUnlock()
+	return downloader
+}
+
+// setDownloader installs d as the Client in use.
+func setDownloader(d *core.Client) {
+	downloaderMu.Lock()
+	downloader = d
+	downloaderMu.Unlock()
+}
+
+// authToken is the shared secret every request to the /queue and /restart
+// control-plane routes must present, via "Authorization: Bearer <token>".
+// Set it with the UGET_SERVER_TOKEN environment variable before starting the
+// server; left unset, those routes reject every request rather than failing
+// open.
+var authToken = os.Getenv("UGET_SERVER_TOKEN")
+
+// requireAuth rejects any request whose Authorization header doesn't match
+// authToken, short-circuiting before the route's handler runs.
+func requireAuth(c *macaron.Context) {
+	got := []byte(c.Req.Header.Get("Authorization"))
+	want := []byte("Bearer " + authToken)
+	if authToken == "" || subtle.ConstantTimeCompare(got, want) != 1 {
+		c.Render.Error(http.StatusUnauthorized, "Unauthorized")
+	}
+}
 
 type macaronLog struct{}
 
@@ -30,18 +74,35 @@ func (w macaronLog) Write(p []byte) (int, error) {
 
 // Run starts the server
 func (s *Server) Run() {
+	d := currentDownloader()
+	if err := d.LoadState(queueStateFile); err != nil && !os.IsNotExist(err) {
+		logrus.Errorf("failed to load persisted queue state: %v", err)
+	}
+	d.Start()
+
 	m := macaron.NewWithLogger(macaronLog{})
 	m.Use(macaron.Renderer())
 	// JSON API
 	m.Group("", func() {
 		m.Get("/serverinfo", wrapJSON(s))
+		m.Get("/bandwidth", s.showBandwidth)
+		m.Get("/bandwidth/stream", s.streamBandwidth)
 		m.Group("/containers", func() {
 			m.Post("", s.createContainer)
 			m.Get("", s.listContainers)
 			m.Get("/:id", s.showContainer)
 			m.Delete("/:id", s.deleteContainer)
 		})
+		m.Group("/queue", func() {
+			m.Get("", s.listQueue)
+			m.Post("/pause", s.pauseQueue)
+			m.Post("/resume", s.resumeQueue)
+			m.Post("/:id/priority", s.setQueuePriority)
+			m.Delete("/:id", s.removeFromQueue)
+		}, requireAuth)
+		m.Post("/restart", requireAuth, s.restart)
 	})
+	registerFaultRoutes(m)
 	// CLICK'N'LOAD v2
 	cnl(m)
 	s.StartedAt = time.Now().Round(time.Minute)
@@ -76,6 +137,113 @@ func (s *Server) deleteContainer(c *macaron.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// showBandwidth returns a single JSON snapshot of the downloader's bandwidth usage.
+func (s *Server) showBandwidth(c *macaron.Context) {
+	c.JSON(http.StatusOK, currentDownloader().Bandwidth())
+}
+
+// streamBandwidth pushes a BandwidthSnapshot every second as a Server-Sent Event,
+// until the client disconnects.
+func (s *Server) streamBandwidth(c *macaron.Context) {
+	flusher, ok := c.Resp.(http.Flusher)
+	if !ok {
+		c.Render.Error(http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	c.Resp.Header().Set("Content-Type", "text/event-stream")
+	c.Resp.Header().Set("Cache-Control", "no-cache")
+	c.Resp.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			data, err := json.Marshal(currentDownloader().Bandwidth())
+			if err != nil {
+				logrus.Errorf("bandwidth stream: %v", err)
+				return
+			}
+			if _, err := fmt.Fprintf(c.Resp, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-c.Req.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// listQueue returns the files currently queued for retrieval, in priority order.
+func (s *Server) listQueue(c *macaron.Context) {
+	c.JSON(http.StatusOK, currentDownloader().ResolvedQueue.List())
+}
+
+// pauseQueue stops retriever workers from picking up new jobs.
+func (s *Server) pauseQueue(c *macaron.Context) {
+	currentDownloader().Pause()
+	c.Status(http.StatusNoContent)
+}
+
+// resumeQueue undoes a prior pauseQueue.
+func (s *Server) resumeQueue(c *macaron.Context) {
+	currentDownloader().Resume()
+	c.Status(http.StatusNoContent)
+}
+
+// setQueuePriority reprioritizes a queued file.
+func (s *Server) setQueuePriority(c *macaron.Context) {
+	var body struct {
+		Priority int `json:"priority"`
+	}
+	if json.NewDecoder(c.Req.Body().ReadCloser()).Decode(&body) != nil {
+		c.Render.Error(http.StatusBadRequest, "Invalid JSON.")
+		return
+	}
+	d := currentDownloader()
+	f := d.ResolvedQueue.Find(c.Params("id"))
+	if f == nil {
+		c.Render.Error(http.StatusNotFound, "No such queued file.")
+		return
+	}
+	d.ResolvedQueue.Set(f, body.Priority)
+	c.Status(http.StatusNoContent)
+}
+
+// removeFromQueue drops a queued file without retrieving it.
+func (s *Server) removeFromQueue(c *macaron.Context) {
+	<-currentDownloader().ResolvedQueue.Remove(c.Params("id"))
+	c.Status(http.StatusNoContent)
+}
+
+// restart persists the current downloader's pending queue, finalizes it
+// (waiting for requests its workers already popped to finish, without
+// draining what is still queued), and rebuilds + restarts it with the
+// persisted state, so queued work survives the restart.
+func (s *Server) restart(c *macaron.Context) {
+	logrus.Info("Restarting downloader...")
+	old := currentDownloader()
+	if err := old.SaveState(queueStateFile); err != nil {
+		c.Render.Error(http.StatusInternalServerError, err.Error())
+		return
+	}
+	<-old.Finalize()
+	old.StopBackground()
+
+	d := core.NewClient()
+	loadErr := d.LoadState(queueStateFile)
+	if loadErr != nil && !os.IsNotExist(loadErr) {
+		logrus.Errorf("failed to load persisted queue state: %v", loadErr)
+	}
+	d.Start()
+	setDownloader(d)
+	if loadErr != nil && !os.IsNotExist(loadErr) {
+		c.Render.Error(http.StatusInternalServerError, loadErr.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func as(ctype string) func(http.ResponseWriter) {
 	return func(w http.ResponseWriter) {
 		w.Header().Set("Content-Type", fmt.Sprintf("%s; charset=utf-8", ctype))